@@ -0,0 +1,220 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package version
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+// CompatibilityRule pairs a range of a component's own versions with the Elasticsearch version constraint
+// required by that range, e.g. Kibana 7.17.x requires Elasticsearch 7.17.x or 8.x.
+type CompatibilityRule struct {
+	// MinComponentVersion is the lowest component version this rule applies to, returned by
+	// CompatibilityMatrix.MinCompatible when this rule is the best match for a given Elasticsearch version.
+	MinComponentVersion Version
+	// ComponentRange selects which component versions this rule applies to.
+	ComponentRange Constraint
+	// ElasticsearchRange is the Elasticsearch version constraint required by a component version matching
+	// ComponentRange.
+	ElasticsearchRange Constraint
+	// ElasticsearchDescription is the human-readable form of ElasticsearchRange, e.g. "7.17.0-7.17.x or 8.x",
+	// used to render error messages.
+	ElasticsearchDescription string
+}
+
+// ComponentCompatibility holds the overall supported version range for a component, plus the ordered list of
+// rules pairing ranges of that component's versions with the Elasticsearch constraint they require.
+type ComponentCompatibility struct {
+	// DisplayName is the human-readable component name used in error messages, e.g. "Kibana".
+	DisplayName string
+	// Range is the overall minimum and maximum supported version for the component, independent of
+	// Elasticsearch.
+	Range MinMaxVersion
+	// Rules is consulted in order; the first rule whose ComponentRange matches a given component version wins.
+	Rules []CompatibilityRule
+}
+
+func (c ComponentCompatibility) matchingRule(componentVersion Version) (CompatibilityRule, bool) {
+	for _, rule := range c.Rules {
+		if rule.ComponentRange.Matches(componentVersion) {
+			return rule, true
+		}
+	}
+	return CompatibilityRule{}, false
+}
+
+func (c ComponentCompatibility) displayNameOr(fallback string) string {
+	if c.DisplayName != "" {
+		return c.DisplayName
+	}
+	return fallback
+}
+
+// CompatibilityMatrix models the cross-product of constraints between each Elastic Stack component (Kibana,
+// APM Server, Beats, Elastic Agent, Enterprise Search, Logstash) and the Elasticsearch version it is deployed
+// alongside. It replaces the previous flat SupportedXVersions variables, which could only express a single
+// version range per component and had no notion of which Elasticsearch versions that range was compatible with.
+type CompatibilityMatrix struct {
+	Components map[string]ComponentCompatibility
+}
+
+// CompatibilityError is returned by CompatibilityMatrix.Check and identifies which axis of the matrix failed:
+// "component" when the component's own version is out of its supported range (or unknown to the matrix), or
+// "elasticsearch" when the component version is fine on its own but incompatible with the given Elasticsearch
+// version.
+type CompatibilityError struct {
+	Axis    string
+	Message string
+}
+
+func (e *CompatibilityError) Error() string {
+	return e.Message
+}
+
+// Check returns a *CompatibilityError if componentVersion and esVersion are not a supported pairing for the
+// named component, or nil if they are. It is intended to be rendered directly in defaulting/validating webhook
+// responses: the message already identifies which component and Elasticsearch versions are at fault.
+func (m CompatibilityMatrix) Check(component string, componentVersion, esVersion Version) error {
+	c, ok := m.Components[component]
+	if !ok {
+		return &CompatibilityError{Axis: "component", Message: fmt.Sprintf("%s is not a known component", component)}
+	}
+
+	if err := c.Range.WithinRange(componentVersion); err != nil {
+		return &CompatibilityError{Axis: "component", Message: fmt.Sprintf("%s %s", c.displayNameOr(component), err)}
+	}
+
+	rule, ok := c.matchingRule(componentVersion)
+	if !ok {
+		return &CompatibilityError{
+			Axis:    "component",
+			Message: fmt.Sprintf("%s %s is not covered by any compatibility rule", c.displayNameOr(component), componentVersion),
+		}
+	}
+
+	if !rule.ElasticsearchRange.Matches(esVersion) {
+		return &CompatibilityError{
+			Axis: "elasticsearch",
+			Message: fmt.Sprintf("%s %s requires Elasticsearch %s, got %s",
+				c.displayNameOr(component), componentVersion, rule.ElasticsearchDescription, esVersion),
+		}
+	}
+
+	return nil
+}
+
+// MinCompatible returns the lowest version of component that is compatible with esVersion, according to the
+// matrix's rules, for use by defaulting webhooks that need to pick a sane default component version for a
+// given Elasticsearch version. It returns the zero Version if component is unknown or no rule matches.
+func (m CompatibilityMatrix) MinCompatible(component string, esVersion Version) Version {
+	c, ok := m.Components[component]
+	if !ok {
+		return Version{}
+	}
+
+	var min *Version
+	for i := range c.Rules {
+		rule := c.Rules[i]
+		if !rule.ElasticsearchRange.Matches(esVersion) {
+			continue
+		}
+		if min == nil || rule.MinComponentVersion.Compare(*min) < 0 {
+			candidate := rule.MinComponentVersion
+			min = &candidate
+		}
+	}
+	if min == nil {
+		return Version{}
+	}
+	return *min
+}
+
+// compatibilityMatrixData and its nested types mirror the embedded YAML schema; they exist solely to decode
+// the raw file before being converted into the richer, pre-parsed CompatibilityMatrix.
+type compatibilityMatrixData struct {
+	Components map[string]componentMatrixData `json:"components"`
+}
+
+type componentMatrixData struct {
+	DisplayName string                  `json:"displayName"`
+	MinVersion  string                  `json:"minVersion"`
+	MaxVersion  string                  `json:"maxVersion"`
+	Rules       []compatibilityRuleData `json:"rules"`
+}
+
+type compatibilityRuleData struct {
+	MinComponentVersion      string `json:"minComponentVersion"`
+	ComponentRange           string `json:"componentRange"`
+	ElasticsearchRange       string `json:"elasticsearchRange"`
+	ElasticsearchDescription string `json:"elasticsearchDescription"`
+}
+
+// parseCompatibilityMatrix decodes raw YAML matrix data into a CompatibilityMatrix, pre-parsing every version
+// and constraint expression so that Check and MinCompatible never need to handle parse errors at request time.
+func parseCompatibilityMatrix(raw []byte) (CompatibilityMatrix, error) {
+	var data compatibilityMatrixData
+	if err := yaml.Unmarshal(raw, &data); err != nil {
+		return CompatibilityMatrix{}, errors.Wrap(err, "failed to parse compatibility matrix")
+	}
+
+	matrix := CompatibilityMatrix{Components: make(map[string]ComponentCompatibility, len(data.Components))}
+	for name, c := range data.Components {
+		minVersion, err := Parse(c.MinVersion)
+		if err != nil {
+			return CompatibilityMatrix{}, errors.Wrapf(err, "component %s: invalid minVersion %q", name, c.MinVersion)
+		}
+		maxVersion, err := Parse(c.MaxVersion)
+		if err != nil {
+			return CompatibilityMatrix{}, errors.Wrapf(err, "component %s: invalid maxVersion %q", name, c.MaxVersion)
+		}
+
+		rules := make([]CompatibilityRule, 0, len(c.Rules))
+		for _, r := range c.Rules {
+			minComponentVersion, err := Parse(r.MinComponentVersion)
+			if err != nil {
+				return CompatibilityMatrix{}, errors.Wrapf(err, "component %s: invalid minComponentVersion %q", name, r.MinComponentVersion)
+			}
+			componentRange, err := ParseConstraint(r.ComponentRange)
+			if err != nil {
+				return CompatibilityMatrix{}, errors.Wrapf(err, "component %s: invalid componentRange %q", name, r.ComponentRange)
+			}
+			esRange, err := ParseConstraint(r.ElasticsearchRange)
+			if err != nil {
+				return CompatibilityMatrix{}, errors.Wrapf(err, "component %s: invalid elasticsearchRange %q", name, r.ElasticsearchRange)
+			}
+			rules = append(rules, CompatibilityRule{
+				MinComponentVersion:      *minComponentVersion,
+				ComponentRange:           componentRange,
+				ElasticsearchRange:       esRange,
+				ElasticsearchDescription: r.ElasticsearchDescription,
+			})
+		}
+
+		matrix.Components[name] = ComponentCompatibility{
+			DisplayName: c.DisplayName,
+			Range:       MinMaxVersion{Min: *minVersion, Max: *maxVersion},
+			Rules:       rules,
+		}
+	}
+
+	return matrix, nil
+}
+
+// DefaultCompatibilityMatrix is loaded at init time from the embedded compatibility matrix YAML (or its test
+// fixture, when built with the matrixtest build tag). Controllers should use it in place of the former
+// SupportedAPMServerVersions, SupportedKibanaVersions, SupportedBeatVersions, SupportedAgentVersions and
+// SupportedEnterpriseSearchVersions variables.
+var DefaultCompatibilityMatrix CompatibilityMatrix
+
+func init() {
+	matrix, err := parseCompatibilityMatrix(compatibilityMatrixYAML)
+	if err != nil {
+		panic(errors.Wrap(err, "failed to load embedded compatibility matrix"))
+	}
+	DefaultCompatibilityMatrix = matrix
+}
@@ -0,0 +1,12 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+//go:build matrixtest
+
+package version
+
+import _ "embed"
+
+//go:embed compatibility_matrix_testdata.yaml
+var compatibilityMatrixYAML []byte
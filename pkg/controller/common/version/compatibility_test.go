@@ -0,0 +1,74 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+//go:build matrixtest
+
+package version
+
+import "testing"
+
+// These tests only run with `go test -tags matrixtest`, which swaps the embedded compatibility matrix YAML for
+// compatibility_matrix_testdata.yaml (see compatibility_data_testtag.go), a small fixture with the same shape
+// as the real matrix.
+
+func TestCompatibilityMatrixCheck(t *testing.T) {
+	m := DefaultCompatibilityMatrix
+
+	if err := m.Check("kibana", MustParse("7.10.0"), MustParse("7.10.0")); err != nil {
+		t.Errorf("expected 7.x Kibana with matching 7.x Elasticsearch to be compatible: %s", err)
+	}
+
+	err := m.Check("kibana", MustParse("7.10.0"), MustParse("8.0.0"))
+	if err == nil {
+		t.Fatal("expected 7.x Kibana with 8.x Elasticsearch to be rejected")
+	}
+	if ce, ok := err.(*CompatibilityError); !ok || ce.Axis != "elasticsearch" {
+		t.Errorf("expected an elasticsearch-axis CompatibilityError, got %v", err)
+	}
+
+	err = m.Check("kibana", MustParse("6.9.0"), MustParse("6.9.0"))
+	if err == nil {
+		t.Fatal("expected a Kibana version below the component's own range to be rejected")
+	}
+	if ce, ok := err.(*CompatibilityError); !ok || ce.Axis != "component" {
+		t.Errorf("expected a component-axis CompatibilityError, got %v", err)
+	}
+
+	if err := m.Check("not-a-component", MustParse("1.0.0"), MustParse("1.0.0")); err == nil {
+		t.Error("expected an unknown component to be rejected")
+	}
+}
+
+// TestCompatibilityMatrixCheckInclusiveMax is a regression test: each component's Range.Max in the matrix data
+// is meant to be a valid, compatible version of that component, not just the upper edge of Range.WithinRange.
+func TestCompatibilityMatrixCheckInclusiveMax(t *testing.T) {
+	m := DefaultCompatibilityMatrix
+	kibana := m.Components["kibana"]
+
+	if err := kibana.Range.WithinRange(kibana.Range.Max); err != nil {
+		t.Fatalf("expected the component's own Max to be within its own Range: %s", err)
+	}
+	if err := m.Check("kibana", kibana.Range.Max, MustParse("8.0.0")); err != nil {
+		t.Errorf("expected the component's advertised maxVersion to be covered by a compatibility rule: %s", err)
+	}
+}
+
+func TestCompatibilityMatrixMinCompatible(t *testing.T) {
+	m := DefaultCompatibilityMatrix
+
+	min := m.MinCompatible("kibana", MustParse("7.10.0"))
+	if !min.IsSame(MustParse("7.0.0")) {
+		t.Errorf("expected the minimum compatible Kibana for Elasticsearch 7.10.0 to be 7.0.0, got %s", min)
+	}
+
+	min = m.MinCompatible("kibana", MustParse("9.0.0"))
+	if !min.IsSame(Version{}) {
+		t.Errorf("expected no compatible Kibana version for an unmatched Elasticsearch version, got %s", min)
+	}
+
+	unknown := m.MinCompatible("not-a-component", MustParse("7.10.0"))
+	if !unknown.IsSame(Version{}) {
+		t.Error("expected an unknown component to yield the zero Version")
+	}
+}
@@ -0,0 +1,297 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package version
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// operator is a single comparison operator used in a Constraint expression.
+type operator string
+
+const (
+	opEQ  operator = "="
+	opGT  operator = ">"
+	opGTE operator = ">="
+	opLT  operator = "<"
+	opLTE operator = "<="
+)
+
+// comparator is a single parsed clause of a Constraint, e.g. ">=7.10.0".
+type comparator struct {
+	op      operator
+	version Version
+}
+
+func (c comparator) matches(v Version) bool {
+	cmp := v.Compare(c.version)
+	switch c.op {
+	case opEQ:
+		return cmp == 0
+	case opGT:
+		return cmp > 0
+	case opGTE:
+		return cmp >= 0
+	case opLT:
+		return cmp < 0
+	case opLTE:
+		return cmp <= 0
+	default:
+		return false
+	}
+}
+
+// Constraint is a parsed version constraint expression, e.g. ">=7.10.0, <8.0.0" or "~7.14 || ^8.2.0".
+//
+// Comma-separated clauses are conjunctions (all must match); clauses separated by "||" are disjunctions
+// (at least one side must match). Conjunction binds tighter than disjunction, so "a,b || c" means "(a AND b) OR c".
+type Constraint struct {
+	expr string
+	// orGroups holds one []comparator per "||"-separated alternative; within a group, every comparator
+	// must match (conjunction).
+	orGroups [][]comparator
+}
+
+// String returns the original constraint expression.
+func (c Constraint) String() string {
+	return c.expr
+}
+
+// Matches returns true if v satisfies the constraint.
+func (c Constraint) Matches(v Version) bool {
+	for _, group := range c.orGroups {
+		matched := true
+		for _, cmp := range group {
+			if !cmp.matches(v) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseConstraint parses a version constraint expression. Supported clause forms are:
+//   - comparisons: ">=7.10.0", "<8.0.0", ">7.0.0", "<=8.0.0", "=7.10.0"
+//   - tilde ranges: "~7.14" (allows patch-level changes, i.e. >=7.14.0 <7.15.0), "~7" (>=7.0.0 <8.0.0)
+//   - caret ranges: "^8.2.0" (allows changes that do not modify the left-most non-zero digit, i.e. >=8.2.0 <9.0.0)
+//   - wildcards: "7.x", "7.14.x" (equivalent to the matching tilde range)
+//   - a bare version, which is treated as an exact match
+//
+// Clauses separated by "," are ANDed together; alternatives separated by "||" are ORed. An expression with an
+// empty clause on either side of a separator is rejected as ambiguous.
+func ParseConstraint(expr string) (Constraint, error) {
+	trimmed := strings.TrimSpace(expr)
+	if trimmed == "" {
+		return Constraint{}, errors.New("constraint expression must not be empty")
+	}
+
+	orParts := strings.Split(trimmed, "||")
+	orGroups := make([][]comparator, 0, len(orParts))
+	for _, orPart := range orParts {
+		andParts := strings.Split(orPart, ",")
+		group := make([]comparator, 0, len(andParts))
+		for _, token := range andParts {
+			token = strings.TrimSpace(token)
+			if token == "" {
+				return Constraint{}, errors.Errorf("ambiguous constraint expression %q: empty clause", expr)
+			}
+			cmps, err := parseClause(token)
+			if err != nil {
+				return Constraint{}, errors.Wrapf(err, "invalid constraint expression %q", expr)
+			}
+			group = append(group, cmps...)
+		}
+		orGroups = append(orGroups, group)
+	}
+
+	return Constraint{expr: trimmed, orGroups: orGroups}, nil
+}
+
+// parseClause parses a single constraint clause into one or more comparators.
+func parseClause(token string) ([]comparator, error) {
+	switch {
+	case strings.HasPrefix(token, ">="):
+		return parseSingleComparator(opGTE, token[2:])
+	case strings.HasPrefix(token, "<="):
+		return parseSingleComparator(opLTE, token[2:])
+	case strings.HasPrefix(token, ">"):
+		return parseSingleComparator(opGT, token[1:])
+	case strings.HasPrefix(token, "<"):
+		return parseSingleComparator(opLT, token[1:])
+	case strings.HasPrefix(token, "="):
+		return parseSingleComparator(opEQ, token[1:])
+	case strings.HasPrefix(token, "~"):
+		return parseTildeRange(token[1:])
+	case strings.HasPrefix(token, "^"):
+		return parseCaretRange(token[1:])
+	case strings.ContainsAny(token, "xX*"):
+		return parseWildcardRange(token)
+	default:
+		return parseSingleComparator(opEQ, token)
+	}
+}
+
+func parseSingleComparator(op operator, versionPart string) ([]comparator, error) {
+	major, minor, patch, err := parsePartial(versionPart)
+	if err != nil {
+		return nil, err
+	}
+	return []comparator{{op: op, version: From(major, valueOr(minor, 0), valueOr(patch, 0))}}, nil
+}
+
+// parseTildeRange implements "~major[.minor[.patch]]": patch-level changes are allowed if minor is specified,
+// minor-level changes are allowed if only major is specified.
+func parseTildeRange(versionPart string) ([]comparator, error) {
+	major, minor, _, err := parsePartial(versionPart)
+	if err != nil {
+		return nil, err
+	}
+	min := From(major, valueOr(minor, 0), 0)
+	var max Version
+	if minor == nil {
+		max = From(major+1, 0, 0)
+	} else {
+		max = From(major, *minor+1, 0)
+	}
+	return []comparator{{op: opGTE, version: min}, {op: opLT, version: max}}, nil
+}
+
+// parseCaretRange implements "^major.minor.patch": changes are allowed as long as the left-most non-zero
+// component of major/minor/patch is not modified.
+func parseCaretRange(versionPart string) ([]comparator, error) {
+	major, minor, patch, err := parsePartial(versionPart)
+	if err != nil {
+		return nil, err
+	}
+	min := From(major, valueOr(minor, 0), valueOr(patch, 0))
+	var max Version
+	switch {
+	case major > 0:
+		max = From(major+1, 0, 0)
+	case valueOr(minor, 0) > 0:
+		max = From(0, *minor+1, 0)
+	default:
+		max = From(0, 0, valueOr(patch, 0)+1)
+	}
+	return []comparator{{op: opGTE, version: min}, {op: opLT, version: max}}, nil
+}
+
+// parseWildcardRange implements "major.x" and "major.minor.x" style expressions.
+func parseWildcardRange(versionPart string) ([]comparator, error) {
+	major, minor, _, err := parsePartial(versionPart)
+	if err != nil {
+		return nil, err
+	}
+	min := From(major, valueOr(minor, 0), 0)
+	var max Version
+	if minor == nil {
+		max = From(major+1, 0, 0)
+	} else {
+		max = From(major, *minor+1, 0)
+	}
+	return []comparator{{op: opGTE, version: min}, {op: opLT, version: max}}, nil
+}
+
+// parsePartial parses a possibly-partial dot-separated version such as "7", "7.14", "7.14.2", or "7.x",
+// returning the major component and pointers to minor/patch when they are present and not a wildcard.
+func parsePartial(s string) (major int, minor, patch *int, err error) {
+	segments := strings.Split(s, ".")
+	if len(segments) == 0 || len(segments) > 3 {
+		return 0, nil, nil, errors.Errorf("invalid version expression: %s", s)
+	}
+
+	major, err = strconv.Atoi(segments[0])
+	if err != nil {
+		return 0, nil, nil, errors.Wrapf(err, "invalid major format: %s", s)
+	}
+	if major < 0 {
+		return 0, nil, nil, errors.Errorf("version component must not be negative: %s", s)
+	}
+
+	if len(segments) >= 2 && !isWildcardSegment(segments[1]) {
+		m, err := strconv.Atoi(segments[1])
+		if err != nil {
+			return 0, nil, nil, errors.Wrapf(err, "invalid minor format: %s", s)
+		}
+		if m < 0 {
+			return 0, nil, nil, errors.Errorf("version component must not be negative: %s", s)
+		}
+		minor = &m
+	}
+
+	if len(segments) == 3 && minor != nil && !isWildcardSegment(segments[2]) {
+		p, err := strconv.Atoi(segments[2])
+		if err != nil {
+			return 0, nil, nil, errors.Wrapf(err, "invalid patch format: %s", s)
+		}
+		if p < 0 {
+			return 0, nil, nil, errors.Errorf("version component must not be negative: %s", s)
+		}
+		patch = &p
+	}
+
+	return major, minor, patch, nil
+}
+
+func isWildcardSegment(s string) bool {
+	return s == "x" || s == "X" || s == "*"
+}
+
+func valueOr(v *int, fallback int) int {
+	if v == nil {
+		return fallback
+	}
+	return *v
+}
+
+// AsConstraint returns mmv expressed as a Constraint, ">=Min,<=Max". Its comparators compare through
+// Version.Compare (see comparator.matches), i.e. by the Format each Version is tagged with; the Format set by
+// WithFormat is not taken into account. WithinRange uses it only for the default semver format.
+func (mmv MinMaxVersion) AsConstraint() Constraint {
+	return Constraint{
+		expr: fmt.Sprintf(">=%s,<=%s", mmv.Min, mmv.Max),
+		orGroups: [][]comparator{{
+			{op: opGTE, version: mmv.Min},
+			{op: opLTE, version: mmv.Max},
+		}},
+	}
+}
+
+// featureRegistry maps a named Elastic stack feature to the version constraint that enables it, so that
+// controllers can ask "does this version have feature X" instead of repeating IsSameOrAfter checks.
+var (
+	featureRegistryMu sync.RWMutex
+	featureRegistry   = map[string]Constraint{}
+)
+
+// RegisterFeature associates a named feature with the version constraint that enables it. Later calls with the
+// same name overwrite the previous constraint, which allows a ConfigMap-backed override to replace the default
+// table for custom builds.
+func RegisterFeature(name string, c Constraint) {
+	featureRegistryMu.Lock()
+	defer featureRegistryMu.Unlock()
+	featureRegistry[name] = c
+}
+
+// HasFeature returns true if v satisfies the constraint registered for the named feature. Unknown feature
+// names are treated as unsupported and return false.
+func HasFeature(name string, v Version) bool {
+	featureRegistryMu.RLock()
+	c, ok := featureRegistry[name]
+	featureRegistryMu.RUnlock()
+	if !ok {
+		return false
+	}
+	return c.Matches(v)
+}
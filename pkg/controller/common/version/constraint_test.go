@@ -0,0 +1,104 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package version
+
+import "testing"
+
+func TestParseConstraintMatches(t *testing.T) {
+	tests := []struct {
+		expr    string
+		matches []string
+		misses  []string
+	}{
+		{expr: ">=7.10.0,<8.0.0", matches: []string{"7.10.0", "7.17.5"}, misses: []string{"7.9.9", "8.0.0"}},
+		{expr: "~7.14", matches: []string{"7.14.0", "7.14.9"}, misses: []string{"7.15.0", "7.13.9"}},
+		{expr: "~7", matches: []string{"7.0.0", "7.17.5"}, misses: []string{"8.0.0"}},
+		{expr: "^8.2.0", matches: []string{"8.2.0", "8.9.9"}, misses: []string{"9.0.0", "8.1.9"}},
+		{expr: "7.x", matches: []string{"7.0.0", "7.17.5"}, misses: []string{"8.0.0"}},
+		{expr: "7.14.x", matches: []string{"7.14.0", "7.14.9"}, misses: []string{"7.15.0"}},
+		{expr: ">=7.10.0,<8.0.0 || >=8.2.0", matches: []string{"7.17.0", "8.2.0", "8.9.9"}, misses: []string{"8.0.0", "8.1.9"}},
+		{expr: "7.10.0", matches: []string{"7.10.0"}, misses: []string{"7.10.1"}},
+	}
+	for _, tt := range tests {
+		c, err := ParseConstraint(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseConstraint(%q): unexpected error: %s", tt.expr, err)
+		}
+		for _, v := range tt.matches {
+			if !c.Matches(MustParse(v)) {
+				t.Errorf("expected %q to match constraint %q", v, tt.expr)
+			}
+		}
+		for _, v := range tt.misses {
+			if c.Matches(MustParse(v)) {
+				t.Errorf("did not expect %q to match constraint %q", v, tt.expr)
+			}
+		}
+	}
+}
+
+func TestParseConstraintRejectsAmbiguousAndEmptyExpressions(t *testing.T) {
+	for _, expr := range []string{"", ">=7.0.0,", ",>=7.0.0", ">=7.0.0 || "} {
+		if _, err := ParseConstraint(expr); err == nil {
+			t.Errorf("expected ParseConstraint(%q) to return an error", expr)
+		}
+	}
+}
+
+func TestFeatureRegistry(t *testing.T) {
+	c, err := ParseConstraint(">=7.10.0")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	RegisterFeature("test-feature", c)
+
+	if !HasFeature("test-feature", MustParse("7.10.0")) {
+		t.Error("expected HasFeature to report true for a matching version")
+	}
+	if HasFeature("test-feature", MustParse("7.9.0")) {
+		t.Error("expected HasFeature to report false for a non-matching version")
+	}
+	if HasFeature("unregistered-feature", MustParse("7.10.0")) {
+		t.Error("expected HasFeature to report false for an unregistered feature")
+	}
+}
+
+func TestParseConstraintRejectsNegativeComponentsWithoutPanicking(t *testing.T) {
+	// parsePartial used to parse a leading "-" via strconv.Atoi without checking its sign, so a negative
+	// component made it through to From(), which panics on negative arguments. parsePartial must reject these
+	// itself so that ParseConstraint returns an error instead of panicking.
+	for _, expr := range []string{"<=-1.0.0", "~-1.0", "^-1.0.0", "-1.x", "-1"} {
+		if _, err := ParseConstraint(expr); err == nil {
+			t.Errorf("expected ParseConstraint(%q) to return an error", expr)
+		}
+	}
+}
+
+func TestMinMaxVersionWithinRangeUsesConstraintEngine(t *testing.T) {
+	mmv := MinMaxVersion{Min: MustParse("7.0.0"), Max: MustParse("8.99.99")}
+
+	if err := mmv.WithinRange(MustParse("7.0.0")); err != nil {
+		t.Errorf("expected the minimum to be within range: %s", err)
+	}
+	if err := mmv.WithinRange(MustParse("8.99.99")); err != nil {
+		t.Errorf("expected the maximum to be within range: %s", err)
+	}
+	if err := mmv.WithinRange(MustParse("6.9.9")); err == nil {
+		t.Error("expected a version below the minimum to be out of range")
+	}
+	if err := mmv.WithinRange(MustParse("9.0.0")); err == nil {
+		t.Error("expected a version above the maximum to be out of range")
+	}
+
+	// WithinRange is defined to behave exactly like mmv.AsConstraint().Matches(v) for the default semver
+	// format; assert the two stay in lockstep rather than hand-rolling a parallel comparison.
+	for _, v := range []string{"6.9.9", "7.0.0", "8.0.0", "8.99.99", "9.0.0"} {
+		wantErr := mmv.WithinRange(MustParse(v)) != nil
+		matches := mmv.AsConstraint().Matches(MustParse(v))
+		if wantErr == matches {
+			t.Errorf("WithinRange(%s) and AsConstraint().Matches(%s) disagree", v, v)
+		}
+	}
+}
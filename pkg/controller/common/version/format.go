@@ -0,0 +1,375 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package version
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Format parses and compares version strings according to a specific versioning scheme. It exists because not
+// every container image attached to an Elastic Stack resource is versioned with SemVer: helper images for Beats
+// or Agent sidecars are sometimes tagged with their upstream project's own Debian, RPM or Maven version string.
+type Format interface {
+	// Name is the registry key under which this Format is looked up, e.g. "semver", "dpkg", "rpm", "maven".
+	Name() string
+	// Parse parses s into a Version according to this format's grammar.
+	Parse(s string) (Version, error)
+	// Compare returns -1, 0 or 1 if a has lower, equal, or higher precedence than b, according to this format.
+	Compare(a, b Version) int
+	// Valid reports whether s can be parsed by this format.
+	Valid(s string) bool
+}
+
+var (
+	formatRegistryMu sync.RWMutex
+	formatRegistry   = map[string]Format{}
+)
+
+// RegisterFormat registers f under f.Name(), overwriting any previously registered Format with the same name.
+func RegisterFormat(f Format) {
+	formatRegistryMu.Lock()
+	defer formatRegistryMu.Unlock()
+	formatRegistry[f.Name()] = f
+}
+
+// GetFormat returns the Format registered under name, if any.
+func GetFormat(name string) (Format, bool) {
+	formatRegistryMu.RLock()
+	defer formatRegistryMu.RUnlock()
+	f, ok := formatRegistry[name]
+	return f, ok
+}
+
+func init() {
+	RegisterFormat(semverFormat{})
+	RegisterFormat(dpkgFormat{})
+	RegisterFormat(rpmFormat{})
+	RegisterFormat(mavenFormat{})
+}
+
+// semverFormat adapts the package's native SemVer Parse/Compare to the Format interface.
+type semverFormat struct{}
+
+func (semverFormat) Name() string { return "semver" }
+
+func (semverFormat) Parse(s string) (Version, error) {
+	v, err := Parse(s)
+	if err != nil {
+		return Version{}, err
+	}
+	v.Format = "semver"
+	return *v, nil
+}
+
+func (semverFormat) Compare(a, b Version) int {
+	return compareSemver(a, b)
+}
+
+func (semverFormat) Valid(s string) bool {
+	_, err := Parse(s)
+	return err == nil
+}
+
+// Raw holds the original, unparsed version string for formats whose precedence cannot be reduced to
+// Major.Minor.Patch (dpkg, rpm, maven). Formats that populate Raw ignore Major/Minor/Patch/PreRelease/Build
+// during Compare and instead compare Raw directly using their own algorithm.
+//
+// It is exported as a field on Version, rather than modeled as a separate type, so that a Version returned by
+// any Format can still flow through the rest of the package (String, labels, etc.) unchanged.
+
+// dpkgFormat implements the Debian package version comparison algorithm described in Debian Policy §5.6.12:
+// an optional "epoch:" prefix compared numerically, followed by "upstream_version[-debian_revision]" compared
+// by alternating runs of non-digits and digits, where non-digit runs sort using a modified ASCII order in
+// which "~" sorts before everything (including the end of a run) and letters sort before non-letters.
+type dpkgFormat struct{}
+
+func (dpkgFormat) Name() string { return "dpkg" }
+
+func (dpkgFormat) Parse(s string) (Version, error) {
+	if s == "" {
+		return Version{}, errors.New("dpkg version string must not be empty")
+	}
+	return Version{Raw: s, Format: "dpkg"}, nil
+}
+
+func (dpkgFormat) Compare(a, b Version) int {
+	return compareDebianStyle(a.Raw, b.Raw)
+}
+
+func (dpkgFormat) Valid(s string) bool {
+	return s != ""
+}
+
+// rpmFormat implements the RPM version comparison algorithm (rpmvercmp), which is derived from dpkg's and
+// differs in that it does not give "~" special pre-release semantics and does not split off an "epoch:" prefix.
+type rpmFormat struct{}
+
+func (rpmFormat) Name() string { return "rpm" }
+
+func (rpmFormat) Parse(s string) (Version, error) {
+	if s == "" {
+		return Version{}, errors.New("rpm version string must not be empty")
+	}
+	return Version{Raw: s, Format: "rpm"}, nil
+}
+
+func (rpmFormat) Compare(a, b Version) int {
+	return compareRunsByGroup(a.Raw, b.Raw, false)
+}
+
+func (rpmFormat) Valid(s string) bool {
+	return s != ""
+}
+
+// compareDebianStyle compares two dpkg version strings, splitting off an optional "epoch:" prefix (compared
+// numerically, missing epoch treated as 0) before comparing the remainder run by run with tilde support.
+func compareDebianStyle(a, b string) int {
+	aEpoch, aRest := splitEpoch(a)
+	bEpoch, bRest := splitEpoch(b)
+	if aEpoch != bEpoch {
+		return compareInt(aEpoch, bEpoch)
+	}
+	return compareRunsByGroup(aRest, bRest, true)
+}
+
+func splitEpoch(s string) (int, string) {
+	if idx := strings.Index(s, ":"); idx >= 0 {
+		if epoch, err := strconv.Atoi(s[:idx]); err == nil {
+			return epoch, s[idx+1:]
+		}
+	}
+	return 0, s
+}
+
+// compareRunsByGroup compares two version strings by splitting each into alternating runs of non-digits and
+// digits and comparing corresponding runs in turn, numeric runs numerically and non-numeric runs
+// lexicographically using compareDebianChars.
+func compareRunsByGroup(a, b string, tildeAware bool) int {
+	aRuns := splitRuns(a)
+	bRuns := splitRuns(b)
+	for i := 0; i < len(aRuns) || i < len(bRuns); i++ {
+		var aRun, bRun string
+		if i < len(aRuns) {
+			aRun = aRuns[i]
+		}
+		if i < len(bRuns) {
+			bRun = bRuns[i]
+		}
+		isDigitRun := (i < len(aRuns) && isDigits(aRuns[i])) || (i < len(bRuns) && isDigits(bRuns[i]))
+		var c int
+		if isDigitRun {
+			c = compareInt(atoiOrZero(aRun), atoiOrZero(bRun))
+		} else {
+			c = compareDebianChars(aRun, bRun, tildeAware)
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// splitRuns splits s into alternating maximal runs of digits and non-digits, in order.
+func splitRuns(s string) []string {
+	var runs []string
+	var current strings.Builder
+	var currentIsDigit bool
+	for i, r := range s {
+		isDigit := r >= '0' && r <= '9'
+		if i == 0 {
+			currentIsDigit = isDigit
+		} else if isDigit != currentIsDigit {
+			runs = append(runs, current.String())
+			current.Reset()
+			currentIsDigit = isDigit
+		}
+		current.WriteRune(r)
+	}
+	if current.Len() > 0 {
+		runs = append(runs, current.String())
+	}
+	return runs
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func atoiOrZero(s string) int {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// debianCharRank ranks a single byte for non-digit run comparison: "~" sorts lowest of all (even below the
+// end of a run), letters sort below every other non-digit character, and everything else sorts by its byte
+// value.
+func debianCharRank(b byte, tildeAware bool) int {
+	if tildeAware && b == '~' {
+		return -2
+	}
+	if b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' {
+		return int(b)
+	}
+	return int(b) + 256
+}
+
+// compareDebianChars compares two non-digit runs character by character using debianCharRank, treating the
+// end of a run as ranking above "~" but below every other character, per Debian Policy §5.6.12.
+func compareDebianChars(a, b string, tildeAware bool) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var aRank, bRank int
+		if i < len(a) {
+			aRank = debianCharRank(a[i], tildeAware)
+		} else if tildeAware {
+			aRank = -1
+		}
+		if i < len(b) {
+			bRank = debianCharRank(b[i], tildeAware)
+		} else if tildeAware {
+			bRank = -1
+		}
+		if aRank != bRank {
+			return compareInt(aRank, bRank)
+		}
+	}
+	return 0
+}
+
+// mavenFormat implements a pragmatic subset of Maven's ComparableVersion algorithm: the version string is
+// split on ".", "-" and digit/letter boundaries into tokens, numeric tokens are compared numerically, and
+// well-known qualifiers (alpha, beta, milestone, rc/cr, snapshot, release/ga/final, sp) are compared using
+// Maven's qualifier ordering before falling back to lexicographic string comparison.
+type mavenFormat struct{}
+
+func (mavenFormat) Name() string { return "maven" }
+
+func (mavenFormat) Parse(s string) (Version, error) {
+	if s == "" {
+		return Version{}, errors.New("maven version string must not be empty")
+	}
+	return Version{Raw: s, Format: "maven"}, nil
+}
+
+func (mavenFormat) Compare(a, b Version) int {
+	return compareMavenTokens(mavenTokens(a.Raw), mavenTokens(b.Raw))
+}
+
+func (mavenFormat) Valid(s string) bool {
+	return s != ""
+}
+
+// mavenQualifierOrder gives the relative precedence of well-known Maven qualifiers; a qualifier not in this
+// table sorts above "rc" and below "" (release), mirroring Maven's treatment of unrecognized qualifiers.
+var mavenQualifierOrder = map[string]int{
+	"alpha":     0,
+	"a":         0,
+	"beta":      1,
+	"b":         1,
+	"milestone": 2,
+	"m":         2,
+	"rc":        3,
+	"cr":        3,
+	"snapshot":  4,
+	"":          5,
+	"ga":        5,
+	"final":     5,
+	"release":   5,
+	"sp":        6,
+}
+
+func mavenQualifierRank(q string) int {
+	if r, ok := mavenQualifierOrder[strings.ToLower(q)]; ok {
+		return r
+	}
+	return 4 // unknown qualifiers share the rank of "snapshot": above "rc" and below "" (release)
+}
+
+// mavenTokens splits a Maven version string into its dot/dash/digit-letter-boundary separated tokens.
+func mavenTokens(s string) []string {
+	var tokens []string
+	var current strings.Builder
+	var currentIsDigit bool
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+	}
+	for i, r := range s {
+		switch {
+		case r == '.' || r == '-':
+			flush()
+		default:
+			isDigit := r >= '0' && r <= '9'
+			if i > 0 && current.Len() > 0 && isDigit != currentIsDigit {
+				flush()
+			}
+			currentIsDigit = isDigit
+			current.WriteRune(r)
+		}
+	}
+	flush()
+	return tokens
+}
+
+// compareMavenTokens compares two token lists, numeric tokens numerically, textual tokens by qualifier order
+// (falling back to lexicographic order within the same rank), and a missing token as equivalent to the
+// release qualifier "" so "1.0" equals "1.0.0" and "1.0-alpha" sorts below "1.0".
+func compareMavenTokens(a, b []string) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var aTok, bTok string
+		if i < len(a) {
+			aTok = a[i]
+		}
+		if i < len(b) {
+			bTok = b[i]
+		}
+		aNum, aIsNum := identifierAsNumber(aTok)
+		bNum, bIsNum := identifierAsNumber(bTok)
+		switch {
+		case aIsNum && bIsNum:
+			if c := compareInt(aNum, bNum); c != 0 {
+				return c
+			}
+		case aIsNum != bIsNum:
+			// A missing/qualifier token defaults to the release rank; a present numeric token outranks any
+			// qualifier ranked below release (e.g. "1.0" > "1.0-alpha") and is outranked by one above it.
+			numRank := mavenQualifierOrder[""]
+			var qualRank int
+			if aIsNum {
+				qualRank = mavenQualifierRank(bTok)
+			} else {
+				qualRank = mavenQualifierRank(aTok)
+			}
+			if aIsNum {
+				return compareInt(numRank, qualRank)
+			}
+			return compareInt(qualRank, numRank)
+		default:
+			if c := compareInt(mavenQualifierRank(aTok), mavenQualifierRank(bTok)); c != 0 {
+				return c
+			}
+			if c := strings.Compare(strings.ToLower(aTok), strings.ToLower(bTok)); c != 0 {
+				return c
+			}
+		}
+	}
+	return 0
+}
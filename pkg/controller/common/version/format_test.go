@@ -0,0 +1,120 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package version
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDpkgFormatCompare(t *testing.T) {
+	f := dpkgFormat{}
+	tests := []struct {
+		lower, higher string
+	}{
+		{"1.0-1", "1.0-2"},
+		{"1.0", "1.1"},
+		{"1.0~rc1", "1.0"},
+		{"0:1.9", "1:0.9"},
+		{"1.0~~", "1.0~"},
+	}
+	for _, tt := range tests {
+		lower, err := f.Parse(tt.lower)
+		if err != nil {
+			t.Fatalf("unexpected error parsing %q: %s", tt.lower, err)
+		}
+		higher, err := f.Parse(tt.higher)
+		if err != nil {
+			t.Fatalf("unexpected error parsing %q: %s", tt.higher, err)
+		}
+		if f.Compare(lower, higher) >= 0 {
+			t.Errorf("expected %q to sort before %q", tt.lower, tt.higher)
+		}
+		if f.Compare(higher, lower) <= 0 {
+			t.Errorf("expected %q to sort after %q", tt.higher, tt.lower)
+		}
+	}
+}
+
+func TestRpmFormatCompareIgnoresTildeSemantics(t *testing.T) {
+	f := rpmFormat{}
+	// unlike dpkg, rpm has no special-cased "~"; it is just another non-digit character.
+	a, err := f.Parse("1.0~rc1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	b, err := f.Parse("1.0")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if f.Compare(a, b) <= 0 {
+		t.Errorf("expected %q to sort after %q under rpm rules", "1.0~rc1", "1.0")
+	}
+}
+
+func TestMavenFormatCompare(t *testing.T) {
+	f := mavenFormat{}
+	tests := []struct {
+		lower, higher string
+	}{
+		{"1.0-alpha", "1.0"},
+		{"1.0-alpha", "1.0-beta"},
+		{"1.0-rc1", "1.0"},
+		{"1.0", "1.0-sp"},
+		{"1.0", "1.1"},
+	}
+	for _, tt := range tests {
+		lower, err := f.Parse(tt.lower)
+		if err != nil {
+			t.Fatalf("unexpected error parsing %q: %s", tt.lower, err)
+		}
+		higher, err := f.Parse(tt.higher)
+		if err != nil {
+			t.Fatalf("unexpected error parsing %q: %s", tt.higher, err)
+		}
+		if f.Compare(lower, higher) >= 0 {
+			t.Errorf("expected %q to sort before %q", tt.lower, tt.higher)
+		}
+	}
+}
+
+func TestFromLabelsTagsParsedFormat(t *testing.T) {
+	v, err := FromLabels(map[string]string{
+		"my-label":  "2:1.0-1",
+		FormatLabel: "dpkg",
+	}, "my-label")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if v.Format != "dpkg" {
+		t.Errorf("expected Format to be tagged \"dpkg\", got %q", v.Format)
+	}
+	if v.Raw != "2:1.0-1" {
+		t.Errorf("expected Raw to be preserved, got %q", v.Raw)
+	}
+}
+
+func TestMinInPodsMixedFormats(t *testing.T) {
+	pods := []corev1.Pod{
+		{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{
+			"version": "8.1.0",
+		}}},
+		{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{
+			"version":   "2:0.5-1",
+			FormatLabel: "dpkg",
+		}}},
+	}
+	min, err := MinInPods(pods, "version")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	// each pod's version must be parsed and compared using its own FormatLabel, not a format assumed from
+	// pod 0 and applied to every pod in the list.
+	if min == nil || min.Format != "dpkg" {
+		t.Fatalf("expected the dpkg-versioned pod to sort first (by Format name), got %+v", min)
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package version
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Strict, when true, makes Parse and MustParse behave like ParseStrict instead of the lenient, backward
+// compatible rules Parse has always used. It defaults to false; controller-runtime startup is expected to set
+// it to true once, before any version strings are parsed, so that a malformed image tag like "8.5.0.0" or
+// "8..1" fails fast instead of silently producing a Version that later compares nonsensically.
+var Strict bool
+
+// identifierPattern matches a single SemVer dot-separated identifier: one or more ASCII alphanumerics or
+// hyphens.
+var identifierPattern = regexp.MustCompile(`^[0-9A-Za-z-]+$`)
+
+// ParseStrict parses version according to the full SemVer 2.0.0 grammar: the major, minor and patch core
+// identifiers must be present, non-empty, and purely numeric with no leading zero (other than "0" itself); an
+// optional "-" introduces dot-separated pre-release identifiers, each of which must be non-empty and match
+// identifierPattern, with the same no-leading-zero rule applied to any that are purely numeric; an optional
+// "+" introduces dot-separated build metadata identifiers, each of which must be non-empty and match
+// identifierPattern (build metadata identifiers are not restricted to numeric no-leading-zero rules, since they
+// never participate in precedence).
+func ParseStrict(version string) (*Version, error) {
+	rest := version
+	build := ""
+	if idx := strings.Index(rest, "+"); idx >= 0 {
+		build = rest[idx+1:]
+		rest = rest[:idx]
+		if build == "" {
+			return nil, errors.Errorf("build metadata must not be empty in version: %s", version)
+		}
+		for _, id := range strings.Split(build, ".") {
+			if id == "" || !identifierPattern.MatchString(id) {
+				return nil, errors.Errorf("invalid build metadata identifier %q in version: %s", id, version)
+			}
+		}
+	}
+
+	core := rest
+	var preRelease []string
+	if idx := strings.Index(rest, "-"); idx >= 0 {
+		core = rest[:idx]
+		label := rest[idx+1:]
+		if label == "" {
+			return nil, errors.Errorf("pre-release must not be empty in version: %s", version)
+		}
+		preRelease = strings.Split(label, ".")
+		for _, id := range preRelease {
+			if id == "" || !identifierPattern.MatchString(id) {
+				return nil, errors.Errorf("invalid pre-release identifier %q in version: %s", id, version)
+			}
+			if isDigits(id) && len(id) > 1 && id[0] == '0' {
+				return nil, errors.Errorf("pre-release identifier %q must not have a leading zero in version: %s", id, version)
+			}
+		}
+	}
+
+	coreSegments := strings.Split(core, ".")
+	if len(coreSegments) < 3 {
+		return nil, errors.Errorf(TooFewSegmentsErrorMessage, version)
+	}
+	if len(coreSegments) > 3 {
+		return nil, errors.Errorf(TooManySegmentsErrorMessage, version)
+	}
+
+	nums := make([]int, len(coreSegments))
+	for i, seg := range coreSegments {
+		if !isDigits(seg) {
+			return nil, errors.Errorf("invalid numeric identifier %q in version: %s", seg, version)
+		}
+		if len(seg) > 1 && seg[0] == '0' {
+			return nil, errors.Errorf("numeric identifier %q must not have a leading zero in version: %s", seg, version)
+		}
+		n, err := strconv.Atoi(seg)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid numeric identifier in version: %s", version)
+		}
+		nums[i] = n
+	}
+
+	return &Version{
+		Major:      nums[0],
+		Minor:      nums[1],
+		Patch:      nums[2],
+		PreRelease: preRelease,
+		Build:      build,
+		Label:      strings.Join(preRelease, "."),
+	}, nil
+}
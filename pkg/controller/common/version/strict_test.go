@@ -0,0 +1,61 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package version
+
+import "testing"
+
+func TestParseStrictRejectsLeadingZeroes(t *testing.T) {
+	for _, s := range []string{"01.0.0", "1.00.0", "1.0.00", "1.0.0-01"} {
+		if _, err := ParseStrict(s); err == nil {
+			t.Errorf("expected ParseStrict(%q) to return an error", s)
+		}
+	}
+}
+
+func TestParseStrictRejectsWrongSegmentCount(t *testing.T) {
+	for _, s := range []string{"1.0", "1.0.0.0"} {
+		if _, err := ParseStrict(s); err == nil {
+			t.Errorf("expected ParseStrict(%q) to return an error", s)
+		}
+	}
+}
+
+func TestParseStrictAcceptsBuildAndPreRelease(t *testing.T) {
+	v, err := ParseStrict("1.2.3-rc.1+build.5")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if v.Major != 1 || v.Minor != 2 || v.Patch != 3 {
+		t.Errorf("expected 1.2.3, got %d.%d.%d", v.Major, v.Minor, v.Patch)
+	}
+	if v.Label != "rc.1" || v.Build != "build.5" {
+		t.Errorf("expected label %q and build %q, got %q and %q", "rc.1", "build.5", v.Label, v.Build)
+	}
+}
+
+func TestStrictTogglesParseBehavior(t *testing.T) {
+	defer func() { Strict = false }()
+
+	Strict = false
+	if _, err := Parse("1.2"); err == nil {
+		t.Error("expected the lenient parser to still reject a wrong segment count")
+	}
+
+	Strict = true
+	if _, err := Parse("01.2.3"); err == nil {
+		t.Error("expected Parse to reject a leading zero once Strict is enabled")
+	}
+}
+
+func TestParseRejectsNegativeRegardlessOfStrict(t *testing.T) {
+	defer func() { Strict = false }()
+
+	for _, strict := range []bool{false, true} {
+		Strict = strict
+		if _, err := Parse("-1.2.3"); err == nil {
+			t.Errorf("expected Parse(%q) to return an error with Strict=%v", "-1.2.3", strict)
+		}
+	}
+}
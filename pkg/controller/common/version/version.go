@@ -18,42 +18,62 @@ import (
 // GlobalMinStackVersion to additional restrict the allowed min version beyond the technical requirements expressed below.
 var GlobalMinStackVersion Version
 
-// supported Stack versions. See https://www.elastic.co/support/matrix#matrix_compatibility
-var (
-	SupportedAPMServerVersions        = MinMaxVersion{Min: From(6, 2, 0), Max: From(8, 99, 99)}
-	SupportedEnterpriseSearchVersions = MinMaxVersion{Min: From(7, 7, 0), Max: From(8, 99, 99)}
-	SupportedKibanaVersions           = MinMaxVersion{Min: From(6, 8, 0), Max: From(8, 99, 99)}
-	SupportedBeatVersions             = MinMaxVersion{Min: From(7, 0, 0), Max: From(8, 99, 99)}
-	// Elastic Agent was introduced in 7.8.0, but as "experimental release" with no migration path forward, hence
-	// picking higher version as minimal supported.
-	SupportedAgentVersions = MinMaxVersion{Min: From(7, 10, 0), Max: From(8, 99, 99)}
-)
+// Supported Stack component versions used to live here as flat MinMaxVersion variables (SupportedKibanaVersions
+// and friends). They have been replaced by DefaultCompatibilityMatrix, which additionally models which
+// Elasticsearch versions each component version is compatible with. See https://www.elastic.co/support/matrix#matrix_compatibility
 
 // MinMaxVersion holds the minimum and maximum supported versions.
 type MinMaxVersion struct {
 	Min Version
 	Max Version
+	// format names the Format used to compare versions against Min/Max. Empty means "semver", which keeps
+	// existing callers working unchanged.
+	format string
+}
+
+// WithFormat returns a copy of mmv that compares against Min/Max using the named Format instead of semver.
+func (mmv MinMaxVersion) WithFormat(name string) MinMaxVersion {
+	mmv.format = name
+	return mmv
+}
+
+func (mmv MinMaxVersion) resolvedFormat() Format {
+	if mmv.format == "" {
+		return semverFormat{}
+	}
+	if f, ok := GetFormat(mmv.format); ok {
+		return f
+	}
+	return semverFormat{}
 }
 
 // WithinRange returns an error if the given version is not within the range of minimum and maximum versions.
+// For the default semver format this is exactly AsConstraint().Matches(v), since the range is itself a
+// conjunction of a lower and an upper bound; for any other format set by WithFormat, v is compared with Min and
+// Max directly via that Format's Compare method, whatever Format v, Min and Max are tagged with.
 func (mmv MinMaxVersion) WithinRange(v Version) error {
-	if !v.IsSameOrAfter(mmv.Min) {
-		return fmt.Errorf("version %s is lower than the lowest supported version of %s", v, mmv.Min)
+	format := mmv.resolvedFormat()
+
+	var inRange bool
+	if _, ok := format.(semverFormat); ok {
+		inRange = mmv.AsConstraint().Matches(v)
+	} else {
+		inRange = format.Compare(v, mmv.Min) >= 0 && format.Compare(mmv.Max, v) >= 0
+	}
+	if inRange {
+		return nil
 	}
 
-	if !mmv.Max.IsSameOrAfter(v) {
-		return fmt.Errorf("version %s is higher than the highest supported version of %s", v, mmv.Max)
+	if format.Compare(v, mmv.Min) < 0 {
+		return fmt.Errorf("version %s is lower than the lowest supported version of %s", v, mmv.Min)
 	}
 
-	return nil
+	return fmt.Errorf("version %s is higher than the highest supported version of %s", v, mmv.Max)
 }
 
 func (mmv MinMaxVersion) WithMin(min Version) MinMaxVersion {
 	if min.IsAfter(mmv.Min) {
-		return MinMaxVersion{
-			Min: min,
-			Max: mmv.Max,
-		}
+		mmv.Min = min
 	}
 	return mmv
 }
@@ -63,14 +83,38 @@ type Version struct {
 	Major int
 	Minor int
 	Patch int
+	// PreRelease holds the dot-separated pre-release identifiers, e.g. ["alpha", "1"] for "1.2.3-alpha.1".
+	// Its presence makes a Version have a lower precedence than the same Major.Minor.Patch without it.
+	PreRelease []string
+	// Build holds the raw build metadata, e.g. "SNAPSHOT" for "1.2.3+SNAPSHOT". It is never taken into account
+	// in precedence comparisons.
+	Build string
+	// Label is a deprecated shim kept for backward compatibility with callers that used to read the raw
+	// string after the first "-". It is populated with the dot-joined PreRelease. Prefer PreRelease.
+	//
+	// Deprecated: use PreRelease instead.
 	Label string
+	// Raw holds the original version string as parsed by a non-semver Format (dpkg, rpm, maven). It is empty
+	// for versions parsed by the semver Format, whose precedence is carried entirely in the fields above.
+	Raw string
+	// Format names the Format (by its registry name) this Version was parsed with, e.g. "dpkg". Empty means
+	// "semver". Comparisons that need to handle a mix of formats (e.g. MinInPods across heterogeneous sidecar
+	// images) use this to compare each Version with the Format it actually came from, rather than assuming
+	// every Version in a list shares one format.
+	Format string
 }
 
 // String formats the version into a string
 func (v Version) String() string {
+	if v.Raw != "" && v.Major == 0 && v.Minor == 0 && v.Patch == 0 && len(v.PreRelease) == 0 && v.Build == "" {
+		return v.Raw
+	}
 	vString := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
-	if v.Label != "" {
-		vString += "-" + v.Label
+	if len(v.PreRelease) > 0 {
+		vString += "-" + strings.Join(v.PreRelease, ".")
+	}
+	if v.Build != "" {
+		vString += "+" + v.Build
 	}
 	return vString
 }
@@ -82,43 +126,74 @@ var (
 	TooManySegmentsErrorMessage = "version string has too many segments: %s"
 )
 
-// Parse returns a parsed version of a string from the format {major}.{minor}.{patch}[-{label}]
+// Parse returns a parsed version of a string from the format {major}.{minor}.{patch}[-{label}]. It is lenient
+// about the content of the label for backward compatibility; set Strict to true, or call ParseStrict directly,
+// to enforce the full SemVer identifier grammar.
 func Parse(version string) (*Version, error) {
-	segments := strings.SplitN(version, ".", 3)
-	if len(segments) < 3 {
+	if Strict {
+		return ParseStrict(version)
+	}
+
+	// build metadata (after "+") is not part of precedence and may itself contain "-" and "." freely, so it
+	// must be split off before looking for the dot-separated core and the pre-release separator.
+	rest := version
+	build := ""
+	if idx := strings.Index(rest, "+"); idx >= 0 {
+		build = rest[idx+1:]
+		rest = rest[:idx]
+	}
+
+	core := rest
+	label := ""
+	if idx := strings.Index(rest, "-"); idx >= 0 {
+		core = rest[:idx]
+		label = rest[idx+1:]
+	}
+
+	// Splitting the core on "." up front, rather than with a bounded SplitN, is what lets a version with too
+	// many segments (e.g. "8.5.0.0") actually be rejected below instead of silently truncated.
+	coreSegments := strings.Split(core, ".")
+	if len(coreSegments) < 3 {
 		return nil, errors.Errorf(TooFewSegmentsErrorMessage, version)
 	}
-	if len(segments) > 4 {
+	if len(coreSegments) > 3 {
 		return nil, errors.Errorf(TooManySegmentsErrorMessage, version)
 	}
 
-	major, err := strconv.Atoi(segments[0])
+	major, err := strconv.Atoi(coreSegments[0])
 	if err != nil {
 		return nil, errors.Wrapf(err, "invalid major format. version: %s", version)
 	}
 
-	minor, err := strconv.Atoi(segments[1])
+	minor, err := strconv.Atoi(coreSegments[1])
 	if err != nil {
 		return nil, errors.Wrapf(err, "invalid minor format. version: %s", version)
 	}
 
-	patchSegments := strings.SplitN(segments[2], "-", 2)
-
-	patch, err := strconv.Atoi(patchSegments[0])
+	patch, err := strconv.Atoi(coreSegments[2])
 	if err != nil {
 		return nil, errors.Wrapf(err, "invalid patch format. version: %s", version)
 	}
 
-	label := ""
-	if len(patchSegments) == 2 {
-		label = patchSegments[1]
+	if major < 0 || minor < 0 || patch < 0 {
+		return nil, errors.Errorf("version component must not be negative: %s", version)
 	}
 
-	return &Version{Major: major, Minor: minor, Patch: patch, Label: label}, nil
+	var preRelease []string
+	if label != "" {
+		preRelease = strings.Split(label, ".")
+	}
+
+	return &Version{Major: major, Minor: minor, Patch: patch, PreRelease: preRelease, Build: build, Label: label}, nil
 }
 
-// From creates a new version from the given major, minor, patch numbers.
+// From creates a new version from the given major, minor, patch numbers. It panics if any of them is negative,
+// since a negative component can never come from a valid version string and would otherwise compare
+// nonsensically.
 func From(major, minor, patch int) Version {
+	if major < 0 || minor < 0 || patch < 0 {
+		panic(fmt.Sprintf("version component must not be negative: %d.%d.%d", major, minor, patch))
+	}
 	return Version{Major: major, Minor: minor, Patch: patch}
 }
 
@@ -132,41 +207,135 @@ func MustParse(version string) Version {
 }
 
 func (v *Version) Copy() *Version {
+	preRelease := make([]string, len(v.PreRelease))
+	copy(preRelease, v.PreRelease)
 	return &Version{
-		Major: v.Major,
-		Minor: v.Minor,
-		Patch: v.Patch,
-		Label: v.Label,
+		Major:      v.Major,
+		Minor:      v.Minor,
+		Patch:      v.Patch,
+		PreRelease: preRelease,
+		Build:      v.Build,
+		Label:      v.Label,
+		Raw:        v.Raw,
+		Format:     v.Format,
 	}
 }
 
-// IsSameOrAfter returns true if the receiver is the same version or newer than the argument. Labels are ignored.
+// Compare returns -1 if the receiver has a lower precedence than other, 0 if they are the same, and 1 if the
+// receiver has a higher precedence than other. Comparison is dispatched through the Format each side was tagged
+// with (see Version.Format and compareTagged), so this gives the same answer as Min/MinInPods for dpkg/rpm/maven
+// Versions, whose precedence lives entirely in Raw rather than Major/Minor/Patch. For the default semver format
+// (or two untagged Versions) this is exactly the SemVer 2.0.0 precedence rules; build metadata is never
+// considered.
+func (v *Version) Compare(other Version) int {
+	return compareTagged(*v, other)
+}
+
+// compareSemver compares a and b using plain SemVer 2.0.0 precedence over Major/Minor/Patch/PreRelease. It is
+// the semver Format's own comparison (see semverFormat.Compare) and the base case compareTagged dispatches to
+// for untagged or semver-tagged Versions.
+func compareSemver(a, b Version) int {
+	if a.Major != b.Major {
+		return compareInt(a.Major, b.Major)
+	}
+	if a.Minor != b.Minor {
+		return compareInt(a.Minor, b.Minor)
+	}
+	if a.Patch != b.Patch {
+		return compareInt(a.Patch, b.Patch)
+	}
+	return comparePreRelease(a.PreRelease, b.PreRelease)
+}
+
+// compareInt compares two ints, returning -1, 0, or 1.
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// comparePreRelease implements the SemVer precedence rule for pre-release identifier lists: a version without
+// a pre-release has higher precedence than one with a pre-release, identifiers are compared left-to-right,
+// numeric identifiers are compared numerically and always have lower precedence than alphanumeric identifiers,
+// and a larger set of identifiers has higher precedence than a smaller set when all preceding identifiers match.
+func comparePreRelease(a, b []string) int {
+	if len(a) == 0 && len(b) == 0 {
+		return 0
+	}
+	if len(a) == 0 {
+		return 1
+	}
+	if len(b) == 0 {
+		return -1
+	}
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := comparePreReleaseIdentifier(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return compareInt(len(a), len(b))
+}
+
+// comparePreReleaseIdentifier compares a single pair of dot-separated pre-release identifiers.
+func comparePreReleaseIdentifier(a, b string) int {
+	aNum, aIsNum := identifierAsNumber(a)
+	bNum, bIsNum := identifierAsNumber(b)
+	switch {
+	case aIsNum && bIsNum:
+		return compareInt(aNum, bNum)
+	case aIsNum && !bIsNum:
+		return -1
+	case !aIsNum && bIsNum:
+		return 1
+	default:
+		return strings.Compare(a, b)
+	}
+}
+
+// identifierAsNumber returns the numeric value of a pre-release identifier and true if it consists only of digits.
+func identifierAsNumber(s string) (int, bool) {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// IsSameOrAfter returns true if the receiver is the same version or newer than the argument, per Compare.
 func (v *Version) IsSameOrAfter(other Version) bool {
-	return v.IsSame(other) || v.IsAfter(other)
+	return v.Compare(other) >= 0
 }
 
 // IsSameOrAfterIgnoringPatch returns true if the receiver is the same version or newer than the argument,
-// considering major and minor versions only (patch is ignored).
+// considering major and minor versions only (patch and pre-release are ignored).
 func (v *Version) IsSameOrAfterIgnoringPatch(other Version) bool {
 	other.Patch = 0
+	other.PreRelease = nil
 	vCopy := v.Copy()
 	vCopy.Patch = 0
+	vCopy.PreRelease = nil
 	return vCopy.IsSameOrAfter(other)
 }
 
-// IsSameOrAfter returns true if the receiver is the same version as the argument. Labels are ignored.
+// IsSame returns true if the receiver is the same version as the argument, per Compare (build metadata is
+// ignored).
 func (v *Version) IsSame(other Version) bool {
-	return v.Major == other.Major && v.Minor == other.Minor && v.Patch == other.Patch
+	return v.Compare(other) == 0
 }
 
-// IsAfter returns true if the receiver version is newer than the argument. Labels are ignored.
+// IsAfter returns true if the receiver version is newer than the argument, per Compare.
 func (v *Version) IsAfter(other Version) bool {
-	return v.Major > other.Major ||
-		(v.Major == other.Major && v.Minor > other.Minor) ||
-		(v.Major == other.Major && v.Minor == other.Minor && v.Patch > other.Patch)
+	return v.Compare(other) > 0
 }
 
-// MinInPods returns the lowest version parsed from labels in the given Pods.
+// MinInPods returns the lowest version parsed from labels in the given Pods. Each Pod's version is parsed and
+// compared using the Format named by that same Pod's own FormatLabel (defaulting to semver), rather than a
+// single Format assumed from one Pod for the whole set; see Min.
 func MinInPods(pods []corev1.Pod, labelName string) (*Version, error) {
 	versions := make([]Version, 0, len(pods))
 	for _, p := range pods {
@@ -179,7 +348,10 @@ func MinInPods(pods []corev1.Pod, labelName string) (*Version, error) {
 	return Min(versions), nil
 }
 
-// MinInStatefulSets returns the lowest version parsed from labels in the given StatefulSets template.
+// MinInStatefulSets returns the lowest version parsed from labels in the given StatefulSets template. Each
+// StatefulSet's version is parsed and compared using the Format named by that same StatefulSet's own
+// FormatLabel (defaulting to semver), rather than a single Format assumed from one StatefulSet for the whole
+// set; see Min.
 func MinInStatefulSets(ssets []appsv1.StatefulSet, labelName string) (*Version, error) {
 	versions := make([]Version, 0, len(ssets))
 	for _, s := range ssets {
@@ -192,10 +364,15 @@ func MinInStatefulSets(ssets []appsv1.StatefulSet, labelName string) (*Version,
 	return Min(versions), nil
 }
 
-// Min returns the minimum version in vs or nil.
+// Min returns the minimum version in vs, or nil if vs is empty. Versions are compared using the Format each was
+// tagged with (see Version.Format) rather than a single Format assumed for the whole slice: two versions
+// sharing a Format are ordered correctly by that Format's own Compare method. Comparing across different
+// Formats has no universally correct answer (a dpkg version and a semver version are not the same kind of
+// thing), so versions are grouped by Format name first and only compared by value within a group; this is
+// deterministic and, critically, never silently misreads one Format's Version using another Format's rules.
 func Min(vs []Version) *Version {
 	sort.SliceStable(vs, func(i, j int) bool {
-		return vs[j].IsSameOrAfter(vs[i])
+		return compareTagged(vs[i], vs[j]) < 0
 	})
 	var v *Version
 	if len(vs) > 0 {
@@ -204,14 +381,64 @@ func Min(vs []Version) *Version {
 	return v
 }
 
+// compareTagged compares a and b using the Format they are each tagged with (see formatOf), falling back to
+// ordering by Format name when they were parsed with different Formats (see Min for why that's the only
+// defensible answer for a cross-Format comparison). Version.Compare dispatches here so that every comparison
+// path - Compare, IsSame, IsAfter, IsSameOrAfter, and Min - agrees on the same rules.
+func compareTagged(a, b Version) int {
+	aName, bName := formatNameOf(a), formatNameOf(b)
+	if aName != bName {
+		return strings.Compare(aName, bName)
+	}
+	return formatOf(a).Compare(a, b)
+}
+
+// formatNameOf returns the name of the Format v was tagged with, defaulting to "semver" if untagged.
+func formatNameOf(v Version) string {
+	if v.Format == "" {
+		return "semver"
+	}
+	return v.Format
+}
+
+// formatOf returns the Format named by v.Format, defaulting to semver if unset or unknown.
+func formatOf(v Version) Format {
+	if v.Format == "" {
+		return semverFormat{}
+	}
+	if f, ok := GetFormat(v.Format); ok {
+		return f
+	}
+	return semverFormat{}
+}
+
+// FormatLabel is the Kubernetes label naming the Format (by its registry name) that the value of a version
+// label should be parsed and compared with. When absent, the semver Format is assumed.
+const FormatLabel = "co.elastic.version-format"
+
+// formatFromLabels returns the Format named by labels[FormatLabel], defaulting to semver if absent or unknown.
+func formatFromLabels(labels map[string]string) Format {
+	name := labels[FormatLabel]
+	if name == "" {
+		return semverFormat{}
+	}
+	if f, ok := GetFormat(name); ok {
+		return f
+	}
+	return semverFormat{}
+}
+
+// FromLabels parses the version label named labelName, using the Format named by FormatLabel if present.
 func FromLabels(labels map[string]string, labelName string) (*Version, error) {
 	labelValue, ok := labels[labelName]
 	if !ok {
 		return nil, errors.Errorf("version label %s is missing", labelName)
 	}
-	v, err := Parse(labelValue)
+	format := formatFromLabels(labels)
+	v, err := format.Parse(labelValue)
 	if err != nil {
 		return nil, errors.Wrapf(err, "version label %s is invalid: %s", labelName, labelValue)
 	}
-	return v, nil
+	v.Format = format.Name()
+	return &v, nil
 }
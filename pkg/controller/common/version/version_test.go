@@ -0,0 +1,146 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package version
+
+import "testing"
+
+func TestParsePrecedenceOrdering(t *testing.T) {
+	// ordered from lowest to highest precedence, per SemVer 2.0.0 §11.
+	ordered := []string{
+		"1.0.0-alpha",
+		"1.0.0-alpha.1",
+		"1.0.0-alpha.beta",
+		"1.0.0-beta",
+		"1.0.0-beta.2",
+		"1.0.0-beta.11",
+		"1.0.0-rc.1",
+		"1.0.0",
+		"1.0.1",
+		"1.1.0",
+		"2.0.0",
+	}
+	for i := 1; i < len(ordered); i++ {
+		lower := MustParse(ordered[i-1])
+		higher := MustParse(ordered[i])
+		if !higher.IsAfter(lower) {
+			t.Errorf("expected %s to be after %s", ordered[i], ordered[i-1])
+		}
+		if lower.IsAfter(higher) {
+			t.Errorf("did not expect %s to be after %s", ordered[i-1], ordered[i])
+		}
+	}
+}
+
+func TestParseRejectsWrongSegmentCount(t *testing.T) {
+	if _, err := Parse("1.2"); err == nil {
+		t.Error("expected Parse(\"1.2\") to return an error")
+	}
+	if _, err := Parse("1.2.3.4"); err == nil {
+		t.Error("expected Parse(\"1.2.3.4\") to return an error")
+	}
+}
+
+func TestIsSame(t *testing.T) {
+	a := MustParse("1.2.3+build1")
+	b := MustParse("1.2.3+build2")
+	if !a.IsSame(b) {
+		t.Error("expected build metadata to be ignored by IsSame")
+	}
+}
+
+func TestIsSameOrAfterIgnoringPatch(t *testing.T) {
+	a := MustParse("1.2.5")
+	b := MustParse("1.2.1")
+	if !a.IsSameOrAfterIgnoringPatch(b) {
+		t.Error("expected 1.2.5 to be same-or-after 1.2.1 when ignoring patch")
+	}
+	if !b.IsSameOrAfterIgnoringPatch(a) {
+		t.Error("expected 1.2.1 to be same-or-after 1.2.5 when ignoring patch")
+	}
+}
+
+func TestParseRejectsNegativeComponents(t *testing.T) {
+	for _, s := range []string{"-1.0.0", "1.-1.0", "1.0.-1"} {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("expected Parse(%q) to return an error", s)
+		}
+	}
+}
+
+func TestCompareDispatchesThroughTaggedFormat(t *testing.T) {
+	// Compare (and IsSame/IsAfter/IsSameOrAfter, which are all defined in terms of it) used to compare only
+	// Major/Minor/Patch/PreRelease directly, which are always the zero value for a dpkg/rpm/maven Version - their
+	// precedence lives entirely in Raw. That made any two such Versions compare as equal regardless of their
+	// actual Raw content. Compare must dispatch through the tagged Format exactly like Min does.
+	low, err := dpkgFormat{}.Parse("1.0-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	high, err := dpkgFormat{}.Parse("99.0-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if low.IsSame(high) {
+		t.Error("did not expect two differently-versioned dpkg Versions to compare as the same")
+	}
+	if low.Compare(high) != -1 {
+		t.Errorf("expected low.Compare(high) to be -1, got %d", low.Compare(high))
+	}
+	if !high.IsAfter(low) {
+		t.Error("expected the higher dpkg Version to be after the lower one")
+	}
+	if low.IsAfter(high) {
+		t.Error("did not expect the lower dpkg Version to be after the higher one")
+	}
+	if !high.IsSameOrAfter(low) {
+		t.Error("expected the higher dpkg Version to be same-or-after the lower one")
+	}
+}
+
+func TestMinSameFormat(t *testing.T) {
+	low, err := dpkgFormat{}.Parse("1.0-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	high, err := dpkgFormat{}.Parse("2.0-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	min := Min([]Version{high, low})
+	if min == nil || min.Raw != "1.0-1" {
+		t.Fatalf("expected %+v to be the minimum, got %+v", low, min)
+	}
+}
+
+func TestMinMixedFormatsGroupsByFormatRatherThanMisreading(t *testing.T) {
+	// A dpkg-formatted Version (Raw-only, Major/Minor/Patch always 0) mixed with a semver one: the old bug
+	// compared every Version in the slice using a single Format assumed from one element, so a dpkg Version's
+	// Major/Minor/Patch (always zero) would be silently read as semver "0.0.0", always making it the lowest
+	// regardless of its actual Raw value, and vice versa a semver Version's empty Raw would sort lowest under
+	// the dpkg algorithm. compareTagged avoids both by never comparing a Version against another Format's
+	// rules: versions are grouped by their own tagged Format name first, which for "dpkg" vs "semver" is
+	// deterministic (alphabetical) rather than an accident of which Format happened to be assumed.
+	dpkgVersion, err := dpkgFormat{}.Parse("99:9.9-9")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	semverVersion := MustParse("0.0.1")
+	semverVersion.Format = "semver"
+
+	min := Min([]Version{semverVersion, dpkgVersion})
+	if min == nil || min.Format != "dpkg" {
+		t.Fatalf("expected the dpkg-tagged version to sort first (by Format name), got %+v", min)
+	}
+}
+
+func TestCopyPreservesFormat(t *testing.T) {
+	v := MustParse("1.2.3")
+	v.Format = "semver"
+	cp := v.Copy()
+	if cp.Format != "semver" {
+		t.Errorf("expected Copy to preserve Format, got %q", cp.Format)
+	}
+}